@@ -0,0 +1,90 @@
+package main
+
+import (
+    "errors"
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "github.com/mazlum/cdnstrip/cdn"
+)
+
+// cacheHeader is written as the first line of every cache file so old
+// cache formats (e.g. the bare "cidr" per line format predating the
+// provider column) can be detected and rejected instead of silently
+// parsing to zero ranges.
+const cacheHeader = "#cdnstrip-cache-v2"
+
+// cacheIsFresh reports whether the cache file at path is younger than ttl.
+func cacheIsFresh(path string, ttl time.Duration) bool {
+    info, err := os.Stat(path)
+    if err != nil {
+        return false
+    }
+    return time.Since(info.ModTime()) < ttl
+}
+
+// parseCacheRanges decodes the "cidr,provider" lines written by
+// writeCacheAtomic back into CDN ranges. It returns an error if data
+// doesn't start with cacheHeader, so a cache file from an older,
+// incompatible format is rejected instead of silently parsing to zero
+// ranges.
+func parseCacheRanges(data []byte) ([]cdn.Range, error) {
+    lines := strings.Split(string(data), "\n")
+    if len(lines) == 0 || lines[0] != cacheHeader {
+        return nil, errors.New("unrecognized cache format (missing or mismatched version header)")
+    }
+
+    var ranges []cdn.Range
+    for _, line := range lines[1:] {
+        parts := strings.SplitN(line, ",", 2)
+        if len(parts) != 2 {
+            continue
+        }
+        _, cidr, err := net.ParseCIDR(parts[0])
+        if err != nil {
+            continue
+        }
+        ranges = append(ranges, cdn.Range{Network: cidr, Provider: parts[1]})
+    }
+    return ranges, nil
+}
+
+// writeCacheAtomic serializes ranges and writes them to path by first
+// writing a uniquely named temporary file in the same directory and
+// renaming it into place, so concurrent readers never observe a
+// partially written cache and concurrent rebuilds never collide on the
+// same temp file.
+func writeCacheAtomic(path string, ranges []cdn.Range) error {
+    var b strings.Builder
+    b.WriteString(cacheHeader)
+    b.WriteString("\n")
+    for i, r := range ranges {
+        b.WriteString(r.Network.String())
+        b.WriteString(",")
+        b.WriteString(r.Provider)
+        if i != len(ranges)-1 {
+            b.WriteString("\n")
+        }
+    }
+
+    tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(tmp.Name())
+
+    if _, err := tmp.WriteString(b.String()); err != nil {
+        tmp.Close()
+        return err
+    }
+    if err := tmp.Close(); err != nil {
+        return err
+    }
+    if err := os.Chmod(tmp.Name(), 0664); err != nil {
+        return err
+    }
+    return os.Rename(tmp.Name(), path)
+}
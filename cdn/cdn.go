@@ -0,0 +1,55 @@
+// Package cdn discovers the IP ranges belonging to known CDN providers and
+// checks whether a given IP falls inside one of them.
+package cdn
+
+import (
+    "context"
+    "fmt"
+    "net"
+)
+
+// Range is a single CIDR block tagged with the provider it was published by.
+type Range struct {
+    Network  *net.IPNet
+    Provider string
+    Source   string
+}
+
+func init() {
+    Register(&urlProvider{name: "cloudflare", url: "https://www.cloudflare.com/ips-v4"})
+    Register(&urlProvider{name: "fastly", url: "https://api.fastly.com/public-ip-list"})
+    Register(&urlProvider{name: "akamai", url: "https://techdocs.akamai.com/property-manager/pdfs/akamai_ipv4_CIDRs.txt"})
+    Register(&urlProvider{name: "incapsula", url: "https://my.incapsula.com/api/integration/v1/ips"})
+    Register(&urlProvider{name: "sucuri", url: "https://www.sucuri.net/denylist/ip-ranges.txt"})
+}
+
+// LoadAll fetches the current IP ranges for every registered provider.
+func LoadAll(ctx context.Context) ([]Range, error) {
+    var ranges []Range
+    for _, p := range Providers() {
+        nets, err := p.Fetch(ctx)
+        if err != nil {
+            return nil, fmt.Errorf("%s: %w", p.Name(), err)
+        }
+        for _, n := range nets {
+            ranges = append(ranges, Range{Network: n, Provider: p.Name()})
+        }
+    }
+    return ranges, nil
+}
+
+// Check reports whether ip belongs to any known provider's range.
+func Check(ranges []Range, ip net.IP) bool {
+    _, ok := Lookup(ranges, ip)
+    return ok
+}
+
+// Lookup returns the name of the provider owning ip, if any.
+func Lookup(ranges []Range, ip net.IP) (provider string, matched bool) {
+    for _, r := range ranges {
+        if r.Network.Contains(ip) {
+            return r.Provider, true
+        }
+    }
+    return "", false
+}
@@ -0,0 +1,84 @@
+package cdn
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net"
+    "net/http"
+    "os"
+)
+
+// RegisterURLProvider registers a provider named name that fetches a plain
+// CIDR list from url, for private or internal CDNs not built into cdnstrip.
+func RegisterURLProvider(name, url string) {
+    Register(&urlProvider{name: name, url: url})
+}
+
+// RegisterFileProvider registers a provider named name that reads a plain
+// CIDR list from a local file.
+func RegisterFileProvider(name, path string) {
+    Register(&fileProvider{name: name, path: path})
+}
+
+// RegisterASNProvider registers a provider named name that resolves an ASN
+// (e.g. "AS13335") to its currently announced IP ranges via RIPEstat.
+func RegisterASNProvider(name, asn string) {
+    Register(&asnProvider{name: name, asn: asn})
+}
+
+type fileProvider struct {
+    name string
+    path string
+}
+
+func (p *fileProvider) Name() string { return p.name }
+
+func (p *fileProvider) Fetch(ctx context.Context) ([]*net.IPNet, error) {
+    f, err := os.Open(p.path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    return parseCIDRList(f)
+}
+
+type asnProvider struct {
+    name string
+    asn  string
+}
+
+func (p *asnProvider) Name() string { return p.name }
+
+func (p *asnProvider) Fetch(ctx context.Context) ([]*net.IPNet, error) {
+    url := fmt.Sprintf("https://stat.ripe.net/data/announced-prefixes/data.json?resource=%s", p.asn)
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    var body struct {
+        Data struct {
+            Prefixes []struct {
+                Prefix string `json:"prefix"`
+            } `json:"prefixes"`
+        } `json:"data"`
+    }
+    if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+        return nil, err
+    }
+
+    var nets []*net.IPNet
+    for _, prefix := range body.Data.Prefixes {
+        if _, cidr, err := net.ParseCIDR(prefix.Prefix); err == nil {
+            nets = append(nets, cidr)
+        }
+    }
+    return nets, nil
+}
@@ -0,0 +1,28 @@
+package cdn
+
+import "net"
+
+// ExpandCIDR returns every address contained in the CIDR block s, including
+// its network and broadcast addresses, so
+// that callers can feed a subnet into a per-IP pipeline.
+func ExpandCIDR(s string) ([]string, error) {
+    ip, ipnet, err := net.ParseCIDR(s)
+    if err != nil {
+        return nil, err
+    }
+
+    var ips []string
+    for i := ip.Mask(ipnet.Mask); ipnet.Contains(i); incIP(i) {
+        ips = append(ips, i.String())
+    }
+    return ips, nil
+}
+
+func incIP(ip net.IP) {
+    for i := len(ip) - 1; i >= 0; i-- {
+        ip[i]++
+        if ip[i] != 0 {
+            break
+        }
+    }
+}
@@ -0,0 +1,74 @@
+package cdn
+
+import (
+    "bufio"
+    "context"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+)
+
+// Provider knows how to fetch a single CDN's current IP ranges.
+type Provider interface {
+    Name() string
+    Fetch(ctx context.Context) ([]*net.IPNet, error)
+}
+
+var registry = map[string]Provider{}
+
+// Register adds p to the set of providers consulted by LoadAll. A provider
+// registered under a name that already exists replaces the previous one,
+// so custom sources can override a built-in provider.
+func Register(p Provider) {
+    registry[p.Name()] = p
+}
+
+// Providers returns every currently registered provider.
+func Providers() []Provider {
+    providers := make([]Provider, 0, len(registry))
+    for _, p := range registry {
+        providers = append(providers, p)
+    }
+    return providers
+}
+
+// urlProvider fetches a plain newline-separated CIDR list over HTTP.
+type urlProvider struct {
+    name string
+    url  string
+}
+
+func (p *urlProvider) Name() string { return p.name }
+
+func (p *urlProvider) Fetch(ctx context.Context) ([]*net.IPNet, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+    if err != nil {
+        return nil, err
+    }
+    resp, err := http.DefaultClient.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    return parseCIDRList(resp.Body)
+}
+
+// parseCIDRList reads one CIDR per line, skipping blanks and "#" comments.
+func parseCIDRList(r io.Reader) ([]*net.IPNet, error) {
+    var nets []*net.IPNet
+    sc := bufio.NewScanner(r)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") {
+            continue
+        }
+        _, cidr, err := net.ParseCIDR(line)
+        if err != nil {
+            continue
+        }
+        nets = append(nets, cidr)
+    }
+    return nets, sc.Err()
+}
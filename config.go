@@ -0,0 +1,75 @@
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// applyConfigFile parses "key=value" pairs from an INI-style file into the
+// global flag set, letting users share defaults across many hosts. Values
+// are applied before flag.Parse runs, so any flag given explicitly on the
+// command line still takes precedence.
+func applyConfigFile(path string) error {
+    f, err := os.Open(path)
+    if err != nil {
+        return err
+    }
+    defer f.Close()
+
+    sc := bufio.NewScanner(f)
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+            continue
+        }
+        key, value, ok := strings.Cut(line, "=")
+        if !ok {
+            continue
+        }
+        key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+        if flag.Lookup(key) != nil {
+            flag.Set(key, value)
+        }
+    }
+    return sc.Err()
+}
+
+// applyConfigEnv lets CDNSTRIP_<FLAG_NAME> environment variables override
+// config file values, while still yielding to explicit CLI flags.
+func applyConfigEnv() {
+    flag.VisitAll(func(fl *flag.Flag) {
+        env := "CDNSTRIP_" + strings.ToUpper(strings.ReplaceAll(fl.Name, "-", "_"))
+        if v, ok := os.LookupEnv(env); ok {
+            flag.Set(fl.Name, v)
+        }
+    })
+}
+
+// dumpConfig prints every flag's effective value, one per line, so users
+// can debug which layer (CLI, env, config file, or default) won.
+func dumpConfig() {
+    flag.VisitAll(func(fl *flag.Flag) {
+        fmt.Printf("%s=%s\n", fl.Name, fl.Value.String())
+    })
+}
+
+// scanConfigFlag extracts the -config/--config value from args without
+// fully parsing them, so it can be applied before flag.Parse runs.
+func scanConfigFlag(args []string) string {
+    for i, a := range args {
+        switch {
+        case a == "-config" || a == "--config":
+            if i+1 < len(args) {
+                return args[i+1]
+            }
+        case strings.HasPrefix(a, "-config="):
+            return strings.TrimPrefix(a, "-config=")
+        case strings.HasPrefix(a, "--config="):
+            return strings.TrimPrefix(a, "--config=")
+        }
+    }
+    return ""
+}
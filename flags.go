@@ -0,0 +1,16 @@
+package main
+
+import "strings"
+
+// stringSliceFlag collects every occurrence of a repeatable flag into a
+// slice, e.g. -provider-url a=1 -provider-url b=2.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+    return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+    *s = append(*s, v)
+    return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import "encoding/json"
+
+// formatLine renders a single result as the bytes to write to disk.
+// provider is empty for non-CDN IPs. format selects the on-disk
+// representation; an unrecognized or empty format falls back to a bare
+// IP per line, or "ip,provider" when provider is set, so CDN hits
+// written to -cdn-out stay tagged with their provider even without an
+// explicit -format.
+func formatLine(format, ip, provider string) []byte {
+    switch format {
+    case "json":
+        var cdnField interface{} = false
+        if provider != "" {
+            cdnField = provider
+        }
+        b, _ := json.Marshal(struct {
+            IP  string      `json:"ip"`
+            CDN interface{} `json:"cdn"`
+        }{ip, cdnField})
+        return append(b, '\n')
+    case "csv":
+        return []byte(ip + "," + provider + "\n")
+    case "tsv":
+        return []byte(ip + "\t" + provider + "\n")
+    default:
+        if provider != "" {
+            return []byte(ip + "," + provider + "\n")
+        }
+        return []byte(ip + "\n")
+    }
+}
@@ -0,0 +1,86 @@
+// Package logger provides the leveled logging used throughout cdnstrip. It
+// replaces ad-hoc log.Printf/print calls with an API that can be silenced,
+// switched to JSON for machine consumption, and selectively traced per
+// category via the CDNSTRIP_TRACE environment variable.
+package logger
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+    "time"
+)
+
+// Logger writes leveled messages to out, optionally as one JSON object per
+// line, and gates Debugf calls by trace category.
+type Logger struct {
+    quiet    bool
+    jsonLog  bool
+    out      *os.File
+    trace    map[string]bool
+    traceAll bool
+}
+
+// New builds a Logger. Debug categories are read from CDNSTRIP_TRACE, a
+// comma-separated list of category names, or "all" to enable every category.
+func New(quiet, jsonLog bool) *Logger {
+    l := &Logger{quiet: quiet, jsonLog: jsonLog, out: os.Stderr, trace: map[string]bool{}}
+    for _, c := range strings.Split(os.Getenv("CDNSTRIP_TRACE"), ",") {
+        c = strings.TrimSpace(c)
+        switch c {
+        case "":
+            continue
+        case "all":
+            l.traceAll = true
+        default:
+            l.trace[c] = true
+        }
+    }
+    return l
+}
+
+// Infof logs an informational message. Suppressed when the logger is quiet.
+func (l *Logger) Infof(format string, args ...interface{}) {
+    if l.quiet {
+        return
+    }
+    l.write("INFO", format, args...)
+}
+
+// Warnf logs a warning. Suppressed when the logger is quiet.
+func (l *Logger) Warnf(format string, args ...interface{}) {
+    if l.quiet {
+        return
+    }
+    l.write("WARN", format, args...)
+}
+
+// Debugf logs a message under category, but only if that category (or
+// "all") was enabled via CDNSTRIP_TRACE.
+func (l *Logger) Debugf(category, format string, args ...interface{}) {
+    if !l.traceAll && !l.trace[category] {
+        return
+    }
+    l.write("DEBUG["+category+"]", format, args...)
+}
+
+// Fatalf logs an error and exits the process with status 1.
+func (l *Logger) Fatalf(format string, args ...interface{}) {
+    l.write("FATAL", format, args...)
+    os.Exit(1)
+}
+
+func (l *Logger) write(level, format string, args ...interface{}) {
+    msg := fmt.Sprintf(format, args...)
+    if l.jsonLog {
+        b, _ := json.Marshal(struct {
+            Time  string `json:"time"`
+            Level string `json:"level"`
+            Msg   string `json:"msg"`
+        }{time.Now().Format(time.RFC3339), level, msg})
+        fmt.Fprintln(l.out, string(b))
+        return
+    }
+    fmt.Fprintf(l.out, "[%s] %s\n", level, msg)
+}
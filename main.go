@@ -2,20 +2,21 @@ package main
 
 import (
     "bufio"
-    "errors"
+    "context"
     "flag"
+    "fmt"
     "io/ioutil"
-    "log"
     "net"
     "os"
     "os/user"
     "runtime"
-    "strconv"
     "strings"
     "sync"
+    "sync/atomic"
     "time"
 
     "github.com/mazlum/cdnstrip/cdn"
+    "github.com/mazlum/cdnstrip/internal/logger"
 
     "github.com/briandowns/spinner"
 )
@@ -25,120 +26,269 @@ func init() {
 }
 
 // Initialize global variables
-var cdnRanges []*net.IPNet
-var mutex sync.Mutex
+var cdnRanges []cdn.Range
 var wg sync.WaitGroup
-var validIP int
-var invalidIP int
-var cdnIP int
+var validIP int64
+var invalidIP int64
+var cdnIP int64
 var s = spinner.New(spinner.CharSets[11], 100*time.Millisecond)
+var l *logger.Logger
 
 func main() {
-    cacheFilePath := getCacheFilePath()
-
     thread := flag.Int("t", 1, "Number of threads")
     input := flag.String("i", "-", "Input [FileName|Stdin]")
     out := flag.String("o", "filtered.txt", "Output file name")
     skipCache := flag.Bool("s", false, "Skip loading cache file for CDN IP ranges")
+    cdnOut := flag.String("cdn-out", "", "Optional file to write CDN-matched IPs to, tagged with their provider")
+    format := flag.String("format", "", "Output format: json|csv|tsv (default: plain IP per line)")
+    cachePath := flag.String("cache-path", "", "Override the cache file location (default ~/.config/cdnstrip.cache)")
+    cacheTTL := flag.Duration("cache-ttl", 24*time.Hour, "Max age of the cache file before it is rebuilt")
+    refresh := flag.Bool("refresh", false, "Force a cache rebuild regardless of its age")
+    asyncRefresh := flag.Bool("async-refresh", false, "Serve a stale cache immediately and rebuild it in the background (exit waits up to 30s for the rebuild to finish)")
+    serveMode := flag.Bool("serve", false, "Serve a long-running HTTP API instead of processing input")
+    listen := flag.String("listen", ":8080", "Address to listen on when -serve is set")
+    listenAddrs := flag.String("listen-addrs", "", "Comma-separated addresses to listen on (overrides -listen)")
+    tlsCert := flag.String("tls-cert", "", "TLS certificate file for -serve (requires -tls-key)")
+    tlsKey := flag.String("tls-key", "", "TLS key file for -serve (requires -tls-cert)")
+    quiet := flag.Bool("quiet", false, "Suppress the spinner; only log errors")
+    jsonLog := flag.Bool("json-log", false, "Emit one JSON log object per line to stderr instead of plain text")
+    _ = flag.String("config", "", "Load flag defaults from an INI-style key=value file")
+    configDump := flag.Bool("config-dump", false, "Print the effective merged configuration and exit")
+    disableProviders := flag.String("disable-provider", "", "Comma-separated provider names to exclude from CDN ranges")
+    var providerURLs, providerASNs, providerFiles stringSliceFlag
+    flag.Var(&providerURLs, "provider-url", "Register a custom provider as name=https://example.com/ranges.txt (repeatable)")
+    flag.Var(&providerASNs, "provider-asn", "Register a custom provider as name=AS13335 (repeatable)")
+    flag.Var(&providerFiles, "provider-file", "Register a custom provider as name=path/to/ranges.txt (repeatable)")
+
+    if cfgPath := scanConfigFlag(os.Args[1:]); cfgPath != "" {
+        if err := applyConfigFile(cfgPath); err != nil {
+            fmt.Fprintf(os.Stderr, "[CONFIG] ERROR: %s\n", err)
+            os.Exit(1)
+        }
+    }
+    applyConfigEnv()
+
     flag.Parse()
 
-    if *input == "" {
+    if *configDump {
+        dumpConfig()
+        return
+    }
+
+    l = logger.New(*quiet, *jsonLog)
+
+    for _, spec := range providerURLs {
+        fatal(registerProvider(spec, cdn.RegisterURLProvider))
+    }
+    for _, spec := range providerASNs {
+        fatal(registerProvider(spec, cdn.RegisterASNProvider))
+    }
+    for _, spec := range providerFiles {
+        fatal(registerProvider(spec, cdn.RegisterFileProvider))
+    }
+
+    disabledProviders := map[string]bool{}
+    for _, name := range strings.Split(*disableProviders, ",") {
+        if name = strings.TrimSpace(name); name != "" {
+            disabledProviders[name] = true
+        }
+    }
+
+    if !*serveMode && *input == "" {
         flag.PrintDefaults()
         os.Exit(1)
     }
 
-    // Start spinner
-    print("\n")
-    s.Writer = os.Stdout
-    s.Start()
+    cacheFilePath := getCacheFilePath(*cachePath)
+
+    useSpinner := !*serveMode && !*quiet && isTerminal(os.Stderr)
+    if useSpinner {
+        print("\n")
+        s.Writer = os.Stdout
+        s.Start()
+    }
 
     // First check if cache exists
     s.Suffix = " Loading for cache file..."
+    l.Debugf("cache", "reading cache file %s", cacheFilePath)
     cahceFile, err := ioutil.ReadFile(cacheFilePath)
-    if err == nil || *skipCache {
-        // read cache file
-        c := strings.Split(string(cahceFile), "\n")
-        if len(c) == 0 {
-            fatal(errors.New("empty cache file"))
+    cacheExists := err == nil
+
+    var cachedRanges []cdn.Range
+    cacheUsable := cacheExists
+    if cacheExists {
+        cachedRanges, err = parseCacheRanges(cahceFile)
+        if err != nil {
+            l.Warnf("cache file %s is in an unrecognized format, forcing a rebuild: %s", cacheFilePath, err)
+            cacheUsable = false
+        } else if len(cahceFile) > 0 && len(cachedRanges) == 0 {
+            l.Warnf("cache file %s parsed to zero ranges, forcing a rebuild", cacheFilePath)
+            cacheUsable = false
         }
-        for _, i := range c {
-            _, cidr, err := net.ParseCIDR(i)
-            if err == nil {
-                // append range
-                cdnRanges = append(cdnRanges, cidr)
+    }
+    customProvidersGiven := len(providerURLs) > 0 || len(providerASNs) > 0 || len(providerFiles) > 0
+    fresh := cacheUsable && !*refresh && !customProvidersGiven && cacheIsFresh(cacheFilePath, *cacheTTL)
+    if customProvidersGiven && cacheUsable {
+        l.Debugf("cache", "custom providers registered via flags, forcing a cache rebuild so they're reflected")
+    }
+
+    var refreshDone chan struct{}
+    switch {
+    case fresh || (*skipCache && cacheUsable):
+        l.Debugf("cache", "using cache as-is (fresh=%v skipCache=%v)", fresh, *skipCache)
+        cdnRanges = cachedRanges
+    case *skipCache:
+        // Explicitly asked to skip the cache decision, but there's
+        // nothing usable to read; proceed with no ranges rather than
+        // forcing an unwanted rebuild.
+        l.Debugf("cache", "skipCache set but no usable cache found, proceeding with no ranges")
+    case cacheUsable && *asyncRefresh:
+        // stale but usable: serve it now, rebuild in the background
+        s.Suffix = " Using stale cache, refreshing in background..."
+        l.Debugf("cache", "cache is stale, serving it while refreshing in background")
+        cdnRanges = cachedRanges
+        refreshDone = make(chan struct{})
+        go func() {
+            defer close(refreshDone)
+            if ranges, err := cdn.LoadAll(context.Background()); err == nil {
+                writeCacheAtomic(cacheFilePath, ranges)
+            } else {
+                l.Warnf("background cache refresh failed: %s", err)
             }
-        }
-    } else {
+        }()
+    default:
         // Create new cache file
         s.Suffix = " Loading all CDN ranges..."
-        ranges, err := cdn.LoadAll()
+        l.Debugf("cache", "cache missing or stale, rebuilding")
+        ranges, err := cdn.LoadAll(context.Background())
         fatal(err)
         cdnRanges = ranges
 
         s.Suffix = " Creating new cache file..."
-        cahceFile, err := os.OpenFile(cacheFilePath, os.O_TRUNC|os.O_RDWR|os.O_CREATE, 0664)
-        fatal(err)
-        for i, r := range cdnRanges {
-            cahceFile.WriteString(r.String())
-            if i != len(cdnRanges)-1 {
-                cahceFile.WriteString("\n")
-            }
+        fatal(writeCacheAtomic(cacheFilePath, cdnRanges))
+    }
+
+    if len(disabledProviders) > 0 {
+        cdnRanges = filterDisabledProviders(cdnRanges, disabledProviders)
+    }
+
+    if *serveMode {
+        addrs := []string{*listen}
+        if *listenAddrs != "" {
+            addrs = strings.Split(*listenAddrs, ",")
         }
-        cahceFile.Close()
+        fatal(serve(addrs, *tlsCert, *tlsKey))
+        return
     }
 
     outFile, err := os.Create(*out)
     fatal(err)
     defer outFile.Close()
 
+    var cdnOutFile *os.File
+    if *cdnOut != "" {
+        cdnOutFile, err = os.Create(*cdnOut)
+        fatal(err)
+        defer cdnOutFile.Close()
+    }
+
+    writeCh := make(chan writeJob, *thread*4)
+    writerDone := runWriter(writeCh)
+
+    // Set before starting the reporter goroutine below, which becomes the
+    // sole writer of s.Suffix from this point on.
+    s.Suffix = " Loading input..."
+    statsStop := make(chan struct{})
+    statsDone := runStatsReporter(statsStop)
+
     channel := make(chan string, *thread*2)
     for i := 0; i < *thread; i++ {
         wg.Add(1)
-        go strip(channel, outFile)
+        go strip(channel, outFile, cdnOutFile, *format, writeCh)
     }
 
     loadInput(*input, channel)
     close(channel)
     wg.Wait()
+    close(writeCh)
+    <-writerDone
+    close(statsStop)
+    <-statsDone
+
+    if refreshDone != nil {
+        l.Debugf("cache", "waiting for background cache refresh to finish before exiting")
+        select {
+        case <-refreshDone:
+        case <-time.After(30 * time.Second):
+            l.Warnf("background cache refresh did not finish within 30s; the rebuilt cache was discarded")
+        }
+    }
 
-    s.Stop()
-    print("[✔]" + s.Suffix + "\n")
+    if useSpinner {
+        s.Stop()
+        print("[✔]" + s.Suffix + "\n")
+    } else {
+        l.Infof("done: valid=%d invalid=%d cdn=%d", validIP, invalidIP, cdnIP)
+    }
 }
 
-func strip(channel chan string, file *os.File) {
+func strip(channel chan string, file *os.File, cdnOutFile *os.File, format string, writeCh chan<- writeJob) {
     defer wg.Done()
     for ip := range channel {
         i := net.ParseIP(ip)
         if i != nil {
-            if cdn.Check(cdnRanges, i) {
-                mutex.Lock()
-                cdnIP++
-                mutex.Unlock()
+            provider, isCDN := cdn.Lookup(cdnRanges, i)
+            l.Debugf("check", "ip=%s cdn=%v provider=%q", i, isCDN, provider)
+            if isCDN {
+                atomic.AddInt64(&cdnIP, 1)
+                if cdnOutFile != nil {
+                    writeCh <- writeJob{file: cdnOutFile, data: formatLine(format, i.String(), provider)}
+                }
             } else {
-                mutex.Lock()
-                validIP++
-                file.WriteString(i.String() + "\n")
-                mutex.Unlock()
+                atomic.AddInt64(&validIP, 1)
+                writeCh <- writeJob{file: file, data: formatLine(format, i.String(), "")}
             }
         } else {
-            mutex.Lock()
-            invalidIP++
-            mutex.Unlock()
+            atomic.AddInt64(&invalidIP, 1)
         }
 
-        // Update spinner
-        updateSpinnerStats()
-
+        l.Debugf("worker", "processed input %q", ip)
     }
 }
 
+// runStatsReporter owns s.Suffix for the lifetime of the run: it is the
+// only goroutine that ever writes it, so strip workers can bump the
+// atomic counters from any number of goroutines without racing on the
+// spinner. It snapshots the counters once on stop so the final suffix is
+// always up to date.
+func runStatsReporter(stop <-chan struct{}) <-chan struct{} {
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        ticker := time.NewTicker(100 * time.Millisecond)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                updateSpinnerStats()
+            case <-stop:
+                updateSpinnerStats()
+                return
+            }
+        }
+    }()
+    return done
+}
+
 func updateSpinnerStats() {
-    mutex.Lock()
-    s.Suffix = "  [ VALID: " + strconv.Itoa(validIP) + " | INVALID: " + strconv.Itoa(invalidIP) + " | CDN: " + strconv.Itoa(cdnIP) + " ]"
-    mutex.Unlock()
+    s.Suffix = fmt.Sprintf("  [ VALID: %d | INVALID: %d | CDN: %d ]",
+        atomic.LoadInt64(&validIP), atomic.LoadInt64(&invalidIP), atomic.LoadInt64(&cdnIP))
 }
 
-func getCacheFilePath() string {
+func getCacheFilePath(override string) string {
+    if override != "" {
+        return override
+    }
     usr, err := user.Current()
     if err != nil {
         fatal(err)
@@ -147,7 +297,6 @@ func getCacheFilePath() string {
 }
 
 func loadInput(param string, inputChan chan<- string) {
-    s.Suffix = " Loading input..."
     var sc *bufio.Scanner
     if param == "-" {
         sc = bufio.NewScanner(os.Stdin)
@@ -168,9 +317,12 @@ func loadInput(param string, inputChan chan<- string) {
         if ip := net.ParseIP(line); ip != nil {
             inputChan <- ip.String()
         } else if cidr, err := cdn.ExpandCIDR(line); err == nil {
+            l.Debugf("load", "expanded CIDR %q into %d addresses", line, len(cidr))
             for _, ip := range cidr {
                 inputChan <- ip
             }
+        } else {
+            l.Debugf("load", "ignoring unparsable input line %q", line)
         }
     }
 }
@@ -181,10 +333,9 @@ func fatal(err error) {
         pc, _, _, ok := runtime.Caller(1)
         details := runtime.FuncForPC(pc)
         if ok && details != nil {
-            log.Printf("[%s] ERROR: %s\n", strings.ToUpper(strings.Split(details.Name(), ".")[1]), err)
+            l.Fatalf("[%s] ERROR: %s", strings.ToUpper(strings.Split(details.Name(), ".")[1]), err)
         } else {
-            log.Printf("[UNKOWN] ERROR: %s\n", err)
+            l.Fatalf("[UNKOWN] ERROR: %s", err)
         }
-        os.Exit(1)
     }
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/mazlum/cdnstrip/cdn"
+)
+
+// registerProvider parses a "name=value" flag spec (as used by
+// -provider-url/-provider-asn/-provider-file) and hands it to register.
+func registerProvider(spec string, register func(name, value string)) error {
+    name, value, ok := strings.Cut(spec, "=")
+    if !ok {
+        return fmt.Errorf("invalid provider spec %q, want name=value", spec)
+    }
+    register(name, value)
+    return nil
+}
+
+// filterDisabledProviders drops every range whose provider is in disabled.
+func filterDisabledProviders(ranges []cdn.Range, disabled map[string]bool) []cdn.Range {
+    filtered := make([]cdn.Range, 0, len(ranges))
+    for _, r := range ranges {
+        if !disabled[r.Provider] {
+            filtered = append(filtered, r)
+        }
+    }
+    return filtered
+}
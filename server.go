@@ -0,0 +1,125 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "net"
+    "net/http"
+    "strings"
+    "sync"
+
+    "github.com/mazlum/cdnstrip/cdn"
+)
+
+// checkResult is the JSON shape returned by the HTTP lookup API.
+type checkResult struct {
+    IP       string `json:"ip"`
+    CDN      bool   `json:"cdn"`
+    Provider string `json:"provider,omitempty"`
+}
+
+func lookupResult(ip net.IP) checkResult {
+    provider, ok := cdn.Lookup(cdnRanges, ip)
+    return checkResult{IP: ip.String(), CDN: ok, Provider: provider}
+}
+
+// serve starts an HTTP server on every address in listenAddrs and blocks
+// until one of them fails, at which point it closes the rest and returns
+// that first error. If tlsCert/tlsKey are both set, each listener is
+// served over TLS.
+//
+// gRPC is out of scope for this server: -serve only exposes the HTTP
+// lookup API below.
+func serve(listenAddrs []string, tlsCert, tlsKey string) error {
+    mux := http.NewServeMux()
+    mux.HandleFunc("/check", handleCheck)
+
+    servers := make([]*http.Server, len(listenAddrs))
+    errCh := make(chan error, len(listenAddrs))
+    var wg sync.WaitGroup
+    for i, addr := range listenAddrs {
+        addr := addr
+        srv := &http.Server{Addr: addr, Handler: mux}
+        servers[i] = srv
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            l.Infof("listening on %s", addr)
+            var err error
+            if tlsCert != "" && tlsKey != "" {
+                err = srv.ListenAndServeTLS(tlsCert, tlsKey)
+            } else {
+                err = srv.ListenAndServe()
+            }
+            if err != nil && err != http.ErrServerClosed {
+                l.Warnf("listener on %s failed: %s", addr, err)
+                errCh <- err
+            }
+        }()
+    }
+
+    firstErr := <-errCh
+    for _, srv := range servers {
+        srv.Close()
+    }
+    wg.Wait()
+    return firstErr
+}
+
+// handleCheck dispatches GET /check?ip=1.2.3.4 (single lookup) and
+// POST /check (bulk lookup) against the in-memory cdnRanges.
+func handleCheck(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        writeCheckResult(w, r.URL.Query().Get("ip"))
+    case http.MethodPost:
+        handleBulkCheck(w, r)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+func writeCheckResult(w http.ResponseWriter, rawIP string) {
+    ip := net.ParseIP(strings.TrimSpace(rawIP))
+    if ip == nil {
+        http.Error(w, "invalid or missing ip parameter", http.StatusBadRequest)
+        return
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(lookupResult(ip))
+}
+
+// handleBulkCheck accepts either a JSON array of IPs or a newline-separated
+// body and returns a JSON array of results.
+func handleBulkCheck(w http.ResponseWriter, r *http.Request) {
+    defer r.Body.Close()
+
+    var ips []string
+    if strings.Contains(r.Header.Get("Content-Type"), "application/json") {
+        if err := json.NewDecoder(r.Body).Decode(&ips); err != nil {
+            http.Error(w, "invalid json body: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+    } else {
+        sc := bufio.NewScanner(r.Body)
+        for sc.Scan() {
+            line := strings.TrimSpace(sc.Text())
+            if line != "" {
+                ips = append(ips, line)
+            }
+        }
+    }
+
+    results := make([]checkResult, 0, len(ips))
+    for _, raw := range ips {
+        ip := net.ParseIP(raw)
+        if ip == nil {
+            continue
+        }
+        results = append(results, lookupResult(ip))
+    }
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(results)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "net"
+    "os"
+    "runtime"
+    "testing"
+
+    "github.com/mazlum/cdnstrip/cdn"
+    "github.com/mazlum/cdnstrip/internal/logger"
+)
+
+func benchRanges(n int) []cdn.Range {
+    r := rand.New(rand.NewSource(1))
+    ranges := make([]cdn.Range, n)
+    for i := range ranges {
+        base := net.IPv4(byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), 0)
+        _, cidr, _ := net.ParseCIDR(fmt.Sprintf("%s/24", base))
+        ranges[i] = cdn.Range{Network: cidr, Provider: "bench"}
+    }
+    return ranges
+}
+
+func benchIPs(n int) []string {
+    r := rand.New(rand.NewSource(2))
+    ips := make([]string, n)
+    for i := range ips {
+        ips[i] = net.IPv4(byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256)), byte(r.Intn(256))).String()
+    }
+    return ips
+}
+
+// BenchmarkStrip drives strip()'s atomic-counter + writer-goroutine
+// pipeline (what the removed mutex used to guard) across
+// runtime.GOMAXPROCS workers. Compare ns/op across
+// `go test -bench=. -cpu=1,2,4,8` runs to see how it scales; this
+// intentionally makes no pass/fail assertion, since a hard wall-clock
+// threshold is flaky on shared or throttled hardware.
+func BenchmarkStrip(b *testing.B) {
+    l = logger.New(true, false)
+    cdnRanges = benchRanges(200)
+    ips := benchIPs(b.N)
+
+    outFile, err := os.CreateTemp(b.TempDir(), "strip-bench-out")
+    if err != nil {
+        b.Fatal(err)
+    }
+    defer outFile.Close()
+
+    workers := runtime.GOMAXPROCS(0)
+    writeCh := make(chan writeJob, workers*4)
+    writerDone := runWriter(writeCh)
+
+    channel := make(chan string, workers*2)
+    for i := 0; i < workers; i++ {
+        wg.Add(1)
+        go strip(channel, outFile, nil, "", writeCh)
+    }
+
+    b.ResetTimer()
+    for _, ip := range ips {
+        channel <- ip
+    }
+    close(channel)
+    wg.Wait()
+    close(writeCh)
+    <-writerDone
+}
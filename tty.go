@@ -0,0 +1,14 @@
+package main
+
+import "os"
+
+// isTerminal reports whether f is connected to an interactive terminal, so
+// the spinner can be disabled automatically when output is redirected or
+// piped.
+func isTerminal(f *os.File) bool {
+    info, err := f.Stat()
+    if err != nil {
+        return false
+    }
+    return info.Mode()&os.ModeCharDevice != 0
+}
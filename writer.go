@@ -0,0 +1,24 @@
+package main
+
+import "os"
+
+// writeJob is a single pre-formatted line destined for one of the output
+// files, handed off by a strip worker to the dedicated writer goroutine.
+type writeJob struct {
+    file *os.File
+    data []byte
+}
+
+// runWriter drains jobs and writes each one to its target file, so strip
+// workers never block on file I/O or contend on a shared lock. The returned
+// channel is closed once jobs is closed and every pending write has been made.
+func runWriter(jobs <-chan writeJob) <-chan struct{} {
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        for job := range jobs {
+            job.file.Write(job.data)
+        }
+    }()
+    return done
+}